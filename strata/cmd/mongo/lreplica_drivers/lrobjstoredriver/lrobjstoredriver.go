@@ -0,0 +1,83 @@
+// Package lrobjstoredriver builds a strata.Driver from a --objstore.config-file
+// document instead of per-backend CLI flags, dispatching on that file's
+// top-level "type" field via the strata/storage registry. Backend packages
+// register themselves by importing them for side effects, the way database/sql
+// drivers do.
+package lrobjstoredriver
+
+import (
+	"io/ioutil"
+	"strconv"
+
+	"github.com/facebookgo/rocks-strata/strata"
+	"github.com/facebookgo/rocks-strata/strata/encstorage"
+	"github.com/facebookgo/rocks-strata/strata/mongo/lreplica"
+	"github.com/facebookgo/rocks-strata/strata/storage"
+)
+
+// ReplicaOptions are used for commands like backup and restore, independent
+// of which storage backend the config file selects.
+type ReplicaOptions struct {
+	MaxBackgroundCopies int    `long:"max-background-copies" default:"16" description:"Backup and restore actions will use up to this many goroutines to copy files"`
+	Port                int    `long:"port" default:"27017" description:"Backup should look for a mongod instance that is listening on this port"`
+	Username            string `long:"username" description:"If auth is configured, specify the username with admin privileges here"`
+	Password            string `long:"password" description:"Password for the specified user."`
+}
+
+// Options define the options needed by this strata command
+type Options struct {
+	ConfigFile       string         `long:"objstore.config-file" description:"Path to a YAML config file with a top-level \"type\" field selecting the storage backend and a \"config\" field holding that backend's settings" required:"true"`
+	EncryptionConfig string         `long:"encryption.config" description:"Path to a YAML config file with a top-level \"type\" field selecting a strata/encstorage KeyProvider. When set, objects are wrapped with client-side AES-256-GCM envelope encryption" optional:"true"`
+	Replica          ReplicaOptions `group:"Replica Options"`
+}
+
+// DriverFactory implements strata.DriverFactory
+type DriverFactory struct {
+	Ops *Options
+}
+
+// GetOptions returns the factory's Options
+func (factory DriverFactory) GetOptions() interface{} {
+	return factory.Ops
+}
+
+// Driver uses the DriverFactory's Options to construct a strata.Driver
+func (factory DriverFactory) Driver() (*strata.Driver, error) {
+	options := factory.GetOptions().(*Options)
+
+	data, err := ioutil.ReadFile(options.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	objStorage, err := storage.LoadConfig(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.EncryptionConfig != "" {
+		encData, err := ioutil.ReadFile(options.EncryptionConfig)
+		if err != nil {
+			return nil, err
+		}
+		keys, err := encstorage.LoadKeyProvider(encData)
+		if err != nil {
+			return nil, err
+		}
+		objStorage = encstorage.New(objStorage, keys, 0)
+	}
+
+	replica, err := lreplica.NewLocalReplica(
+		options.Replica.MaxBackgroundCopies,
+		strconv.Itoa(options.Replica.Port),
+		options.Replica.Username,
+		options.Replica.Password,
+	)
+	if err != nil {
+		return nil, err
+	}
+	manager, err := strata.NewSnapshotManager(replica, objStorage)
+	if err != nil {
+		return nil, err
+	}
+	return &strata.Driver{Manager: manager}, err
+}
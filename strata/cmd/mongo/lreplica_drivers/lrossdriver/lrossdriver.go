@@ -5,7 +5,7 @@ import (
 	"os"
 	"strconv"
 
-	"github.com/PinIdea/oss-aliyun-go"
+	sdk "github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/facebookgo/rocks-strata/strata"
 	"github.com/facebookgo/rocks-strata/strata/mongo/lreplica"
 	"github.com/facebookgo/rocks-strata/strata/ossstorage"
@@ -13,10 +13,15 @@ import (
 
 // OSSOptions are common to all commands
 type OSSOptions struct {
-	Region       string `short:"R" long:"region" description:"Aliyun region name, such as \"oss-cn-hangzhou\"" default:"oss-cn-hangzhou"`
-	BucketName   string `short:"b" long:"bucket" description:"Name of OSS bucket used to store the backups" required:"true"`
-	BucketPrefix string `short:"p" long:"bucket-prefix" description:"Prefix used when storing and retrieving files. Optional" optional:"true"`
-	BucketACL    string `short:"a" long:"bucket-acl" description:"ACL is one of private, public-read, public-read-write, authenticated-read, bucket-owner-read, or bucket-owner-full-control" default:"private"`
+	Endpoint      string `short:"e" long:"endpoint" description:"OSS endpoint, such as \"oss-cn-hangzhou.aliyuncs.com\"" default:"oss-cn-hangzhou.aliyuncs.com"`
+	BucketName    string `short:"b" long:"bucket" description:"Name of OSS bucket used to store the backups" required:"true"`
+	BucketPrefix  string `short:"p" long:"bucket-prefix" description:"Prefix used when storing and retrieving files. Optional" optional:"true"`
+	BucketACL     string `short:"a" long:"bucket-acl" description:"ACL is one of private, public-read, public-read-write, authenticated-read, bucket-owner-read, or bucket-owner-full-control" default:"private"`
+	PartSize      int64  `long:"part-size" default:"10485760" description:"Size in bytes of each part used by PutReader/Get's resumable multipart transfers. Must be at least 100KiB"`
+	CheckpointDir string `long:"checkpoint-dir" description:"Directory for resumable-transfer checkpoint files. Defaults to a directory under the OS temp dir" optional:"true"`
+	Retries       int    `long:"retries" default:"5" description:"Number of times to retry an OSS request that fails with a 5xx or network error"`
+	STSToken      string `long:"sts-token" description:"STS security token to use alongside OSS_ACCESS_KEY_ID/OSS_SECRET_ACCESS_KEY for a RAM role session. Optional" optional:"true"`
+	RoleArn       string `long:"role-arn" description:"RAM role ARN that --sts-token was assumed from. Informational only; rocks-strata does not call AssumeRole itself" optional:"true"`
 }
 
 // ReplicaOptions are used for commands like backup and restore
@@ -53,12 +58,19 @@ func (factory DriverFactory) Driver() (*strata.Driver, error) {
 		return nil, errors.New("Environment variables OSS_ACCESS_KEY_ID and OSS_SECRET_ACCESS_KEY must be set")
 	}
 
-	ossstorage, err := ossstorage.NewOSSStorage(
-		options.OSS.Region,
-		oss.Auth{AccessKey: accessKey, SecretKey: secretKey},
-		options.OSS.BucketName,
-		options.OSS.BucketPrefix,
-		oss.ACL(options.OSS.BucketACL))
+	ossstorage, err := ossstorage.NewOSSStorage(ossstorage.Options{
+		Endpoint:        options.OSS.Endpoint,
+		AccessKeyID:     accessKey,
+		AccessKeySecret: secretKey,
+		STSToken:        options.OSS.STSToken,
+		RoleArn:         options.OSS.RoleArn,
+		BucketName:      options.OSS.BucketName,
+		Prefix:          options.OSS.BucketPrefix,
+		BucketACL:       sdk.ACLType(options.OSS.BucketACL),
+		PartSize:        options.OSS.PartSize,
+		CheckpointDir:   options.OSS.CheckpointDir,
+		Retries:         options.OSS.Retries,
+	})
 	if err != nil {
 		return nil, err
 	}
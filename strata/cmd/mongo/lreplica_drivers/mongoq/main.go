@@ -9,17 +9,29 @@ import (
 	"os"
 	"strings"
 
-	"github.com/takuan-osho/rocks-strata/strata/cmd/mongo/lreplica_drivers/lrossdriver"
+	"github.com/takuan-osho/rocks-strata/strata/cmd/mongo/lreplica_drivers/lrobjstoredriver"
+
+	// Self-register storage backends with strata/storage so that
+	// --objstore.config-file can select them by their "type" field.
+	_ "github.com/takuan-osho/rocks-strata/strata/ocistorage"
+	_ "github.com/takuan-osho/rocks-strata/strata/ossstorage"
 
 	"github.com/facebookgo/rocks-strata/strata/cmd/mongo/lreplica_drivers/lrs3driver"
 	"github.com/facebookgo/rocks-strata/strata/mongo"
 )
 
+// lrs3driver's underlying S3 storage still lives upstream in
+// facebookgo/rocks-strata and hasn't been ported to self-register with
+// strata/storage yet, so a "type: s3" config file isn't usable today.
+// Existing REMOTE_STORAGE=s3 deployments (and the old unset-env-var default)
+// keep dispatching straight to lrs3driver instead of being forced onto
+// --objstore.config-file; any other REMOTE_STORAGE value opts into the new
+// config-driven path.
 func main() {
 	switch strings.ToLower(os.Getenv("REMOTE_STORAGE")) {
-	case "oss":
-		mongoq.RunCLI(lrossdriver.DriverFactory{Ops: &lrossdriver.Options{}})
-	default:
+	case "", "s3":
 		mongoq.RunCLI(lrs3driver.DriverFactory{Ops: &lrs3driver.Options{}})
+	default:
+		mongoq.RunCLI(lrobjstoredriver.DriverFactory{Ops: &lrobjstoredriver.Options{}})
 	}
 }
@@ -0,0 +1,36 @@
+package encstorage
+
+import (
+	"fmt"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Config is the shape of a --encryption.config document. Type selects which
+// KeyProvider implementation wraps the data key, and Config is that
+// provider's settings.
+//
+//	type: keyfile
+//	config:
+//	  path: /etc/strata/encryption.key
+type Config struct {
+	Type   string      `yaml:"type"`
+	Config interface{} `yaml:"config"`
+}
+
+// LoadKeyProvider parses a --encryption.config document and builds the
+// KeyProvider it describes, using the provider registered under Type.
+func LoadKeyProvider(data []byte) (KeyProvider, error) {
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Type == "" {
+		return nil, fmt.Errorf("encstorage: config is missing a top-level \"type\" field")
+	}
+	raw, err := yaml.Marshal(cfg.Config)
+	if err != nil {
+		return nil, err
+	}
+	return newKeyProvider(cfg.Type, raw)
+}
@@ -0,0 +1,311 @@
+// Package encstorage wraps a strata.Storage with client-side AES-256-GCM
+// envelope encryption, so that backups can be stored on a shared bucket
+// without trusting that bucket with the plaintext.
+//
+// Each object gets its own random 256-bit data encryption key (DEK). The DEK
+// is wrapped by a pluggable KeyProvider (a static keyfile or a KMS) and
+// stored, along with a random base nonce, in a small header prepended to the
+// ciphertext. PutReader encrypts the body as a sequence of fixed-size framed
+// chunks so that it never has to buffer the whole object, and Get decrypts
+// those frames lazily as they are read.
+package encstorage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/facebookgo/rocks-strata/strata"
+)
+
+const (
+	headerMagic      = "strataenc"
+	headerVersion    = 1
+	dekSize          = 32      // AES-256
+	nonceSize        = 12      // standard GCM nonce size
+	defaultChunkSize = 1 << 20 // 1 MiB of plaintext per frame
+)
+
+// KeyProvider wraps and unwraps a per-object data encryption key using a
+// key-encryption key (KEK) that EncStorage itself never sees in the clear.
+// Implementations include a static keyfile and KMS-backed providers.
+type KeyProvider interface {
+	WrapKey(dek []byte) (wrapped []byte, err error)
+	UnwrapKey(wrapped []byte) (dek []byte, err error)
+}
+
+// EncStorage implements strata.Storage by encrypting objects before passing
+// them to an underlying strata.Storage, and decrypting them after reading.
+type EncStorage struct {
+	inner     strata.Storage
+	keys      KeyProvider
+	chunkSize int
+}
+
+// New wraps inner with envelope encryption. keys wraps and unwraps each
+// object's data key. chunkSize is the plaintext size of each AES-GCM frame;
+// a chunkSize of 0 uses defaultChunkSize.
+func New(inner strata.Storage, keys KeyProvider, chunkSize int) *EncStorage {
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	return &EncStorage{inner: inner, keys: keys, chunkSize: chunkSize}
+}
+
+// Put places data at path, encrypted under a fresh data key.
+func (s *EncStorage) Put(path string, data []byte) error {
+	return s.PutReader(path, bytes.NewReader(data))
+}
+
+// PutReader consumes reader, encrypts it under a fresh data key, and stores
+// the header followed by the framed ciphertext at path in the underlying
+// storage.
+func (s *EncStorage) PutReader(path string, reader io.Reader) error {
+	dek := make([]byte, dekSize)
+	if _, err := rand.Read(dek); err != nil {
+		return err
+	}
+	gcm, err := newGCM(dek)
+	if err != nil {
+		return err
+	}
+
+	baseNonce := make([]byte, nonceSize)
+	if _, err := rand.Read(baseNonce); err != nil {
+		return err
+	}
+
+	wrappedKey, err := s.keys.WrapKey(dek)
+	if err != nil {
+		return err
+	}
+
+	header, err := encodeHeader(wrappedKey, baseNonce)
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(encryptFrames(pw, reader, gcm, baseNonce, s.chunkSize))
+	}()
+
+	// If s.inner.PutReader returns before reading pr to EOF (e.g. it aborts a
+	// multipart upload after one part fails, without draining the rest of
+	// its input), the encryptFrames goroutine above would otherwise block
+	// forever on pw.Write with nothing left to read it. Closing pr here
+	// unblocks that write with io.ErrClosedPipe so the goroutine always
+	// exits; it's a no-op if pr already reached EOF normally.
+	putErr := s.inner.PutReader(path, io.MultiReader(bytes.NewReader(header), pr))
+	pr.Close()
+	return putErr
+}
+
+// Get returns a reader that decrypts the object at path. The returned reader
+// still drives the underlying storage's reader to completion on Close, so
+// any checksum that the underlying Get verifies (e.g. via
+// strata.NewChecksummingReader against the ciphertext ETag) is verified as
+// usual.
+func (s *EncStorage) Get(path string) (io.ReadCloser, error) {
+	inner, err := s.inner.Get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, baseNonce, err := decodeHeader(inner)
+	if err != nil {
+		inner.Close()
+		return nil, err
+	}
+	dek, err := s.keys.UnwrapKey(wrappedKey)
+	if err != nil {
+		inner.Close()
+		return nil, err
+	}
+	gcm, err := newGCM(dek)
+	if err != nil {
+		inner.Close()
+		return nil, err
+	}
+
+	return &decryptingReader{inner: inner, gcm: gcm, nonce: append([]byte(nil), baseNonce...)}, nil
+}
+
+// Delete removes the object at path from the underlying storage.
+func (s *EncStorage) Delete(path string) error {
+	return s.inner.Delete(path)
+}
+
+// List returns a list of objects (up to maxSize) with the given prefix.
+// Listing does not need to touch encrypted contents, so it passes straight
+// through to the underlying storage.
+func (s *EncStorage) List(prefix string, maxSize int) ([]string, error) {
+	return s.inner.List(prefix, maxSize)
+}
+
+// Lock passes through to the underlying storage.
+func (s *EncStorage) Lock(path string) error {
+	return s.inner.Lock(path)
+}
+
+// Unlock passes through to the underlying storage.
+func (s *EncStorage) Unlock(path string) error {
+	return s.inner.Unlock(path)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encodeHeader builds the header prepended to every encrypted object:
+//
+//	[9]byte  magic ("strataenc")
+//	[1]byte  version
+//	[2]byte  len(wrappedKey), big-endian
+//	[...]    wrappedKey
+//	[12]byte baseNonce
+func encodeHeader(wrappedKey []byte, baseNonce []byte) ([]byte, error) {
+	if len(wrappedKey) > 1<<16-1 {
+		return nil, errors.New("encstorage: wrapped key is too large to encode")
+	}
+	if len(baseNonce) != nonceSize {
+		return nil, errors.New("encstorage: base nonce must be 12 bytes")
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString(headerMagic)
+	buf.WriteByte(headerVersion)
+	var lenBuf [2]byte
+	binary.BigEndian.PutUint16(lenBuf[:], uint16(len(wrappedKey)))
+	buf.Write(lenBuf[:])
+	buf.Write(wrappedKey)
+	buf.Write(baseNonce)
+	return buf.Bytes(), nil
+}
+
+func decodeHeader(r io.Reader) (wrappedKey []byte, baseNonce []byte, err error) {
+	magicAndVersion := make([]byte, len(headerMagic)+1)
+	if _, err := io.ReadFull(r, magicAndVersion); err != nil {
+		return nil, nil, err
+	}
+	if string(magicAndVersion[:len(headerMagic)]) != headerMagic {
+		return nil, nil, errors.New("encstorage: missing encryption header; object is not encrypted by this package")
+	}
+	if magicAndVersion[len(headerMagic)] != headerVersion {
+		return nil, nil, fmt.Errorf("encstorage: unsupported header version %d", magicAndVersion[len(headerMagic)])
+	}
+
+	var lenBuf [2]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return nil, nil, err
+	}
+	wrappedKey = make([]byte, binary.BigEndian.Uint16(lenBuf[:]))
+	if _, err := io.ReadFull(r, wrappedKey); err != nil {
+		return nil, nil, err
+	}
+
+	baseNonce = make([]byte, nonceSize)
+	if _, err := io.ReadFull(r, baseNonce); err != nil {
+		return nil, nil, err
+	}
+	return wrappedKey, baseNonce, nil
+}
+
+// encryptFrames reads r in chunkSize plaintext chunks, seals each with gcm
+// under a nonce derived from baseNonce and an incrementing frame counter, and
+// writes each frame to w as a 4-byte big-endian length prefix followed by the
+// sealed bytes.
+func encryptFrames(w io.Writer, r io.Reader, gcm cipher.AEAD, baseNonce []byte, chunkSize int) error {
+	buf := make([]byte, chunkSize)
+	nonce := append([]byte(nil), baseNonce...)
+	var counter uint32
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if readErr != nil && readErr != io.ErrUnexpectedEOF && readErr != io.EOF {
+			return readErr
+		}
+		if n > 0 {
+			binary.BigEndian.PutUint32(nonce[nonceSize-4:], counter)
+			ciphertext := gcm.Seal(nil, nonce, buf[:n], nil)
+			var lenBuf [4]byte
+			binary.BigEndian.PutUint32(lenBuf[:], uint32(len(ciphertext)))
+			if _, err := w.Write(lenBuf[:]); err != nil {
+				return err
+			}
+			if _, err := w.Write(ciphertext); err != nil {
+				return err
+			}
+			counter++
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			return nil
+		}
+	}
+}
+
+// decryptingReader decrypts the framed ciphertext written by encryptFrames
+// as it is read.
+type decryptingReader struct {
+	inner   io.ReadCloser
+	gcm     cipher.AEAD
+	nonce   []byte
+	counter uint32
+	buf     []byte
+	err     error
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		var lenBuf [4]byte
+		if _, err := io.ReadFull(d.inner, lenBuf[:]); err != nil {
+			if err == io.EOF {
+				d.err = io.EOF
+			} else {
+				d.err = err
+			}
+			return 0, d.err
+		}
+		ciphertext := make([]byte, binary.BigEndian.Uint32(lenBuf[:]))
+		if _, err := io.ReadFull(d.inner, ciphertext); err != nil {
+			d.err = err
+			return 0, err
+		}
+		binary.BigEndian.PutUint32(d.nonce[nonceSize-4:], d.counter)
+		plain, err := d.gcm.Open(nil, d.nonce, ciphertext, nil)
+		if err != nil {
+			d.err = err
+			return 0, err
+		}
+		d.counter++
+		d.buf = plain
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// Close drains any unread ciphertext from d.inner before closing it, so that
+// a caller who stops reading early (e.g. after finding what it needed) still
+// gets the same corruption check that reading to EOF would have: closing
+// without draining would leave the underlying reader's own Close (e.g.
+// strata.NewChecksummingReader) without the bytes it needs to verify its
+// checksum.
+func (d *decryptingReader) Close() error {
+	_, err := io.Copy(ioutil.Discard, d.inner)
+	if closeErr := d.inner.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
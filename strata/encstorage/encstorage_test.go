@@ -0,0 +1,191 @@
+package encstorage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// staticKeyProvider wraps and unwraps by XOR with a fixed key, just enough to
+// exercise EncStorage without pulling in a real KMS.
+type staticKeyProvider struct{}
+
+func (staticKeyProvider) WrapKey(dek []byte) ([]byte, error) {
+	wrapped := make([]byte, len(dek))
+	for i, b := range dek {
+		wrapped[i] = b ^ 0x42
+	}
+	return wrapped, nil
+}
+
+func (staticKeyProvider) UnwrapKey(wrapped []byte) ([]byte, error) {
+	dek := make([]byte, len(wrapped))
+	for i, b := range wrapped {
+		dek[i] = b ^ 0x42
+	}
+	return dek, nil
+}
+
+// fakeChecksummingReader stands in for strata.NewChecksummingReader: Close
+// reports an error if the reader was closed before being read to EOF, the
+// same way a real checksum check would never run over the unread tail.
+type fakeChecksummingReader struct {
+	r    *bytes.Reader
+	read bool
+}
+
+func (f *fakeChecksummingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		f.read = true
+	}
+	return n, err
+}
+
+func (f *fakeChecksummingReader) Close() error {
+	if f.r.Len() > 0 {
+		return errors.New("fakeChecksummingReader: closed before reaching EOF, checksum not verified")
+	}
+	return nil
+}
+
+// fakeStorage is a minimal in-memory strata.Storage good enough to drive
+// EncStorage's PutReader/Get round trip in tests.
+type fakeStorage struct {
+	objects map[string][]byte
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{objects: make(map[string][]byte)}
+}
+
+func (f *fakeStorage) Put(path string, data []byte) error {
+	f.objects[path] = append([]byte(nil), data...)
+	return nil
+}
+
+func (f *fakeStorage) PutReader(path string, reader io.Reader) error {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	return f.Put(path, data)
+}
+
+func (f *fakeStorage) Get(path string) (io.ReadCloser, error) {
+	data, ok := f.objects[path]
+	if !ok {
+		return nil, errors.New("fakeStorage: not found")
+	}
+	return &fakeChecksummingReader{r: bytes.NewReader(data)}, nil
+}
+
+func (f *fakeStorage) Delete(path string) error {
+	delete(f.objects, path)
+	return nil
+}
+
+func (f *fakeStorage) List(prefix string, maxSize int) ([]string, error) {
+	var items []string
+	for path := range f.objects {
+		items = append(items, path)
+	}
+	return items, nil
+}
+
+func (f *fakeStorage) Lock(path string) error   { return nil }
+func (f *fakeStorage) Unlock(path string) error { return nil }
+
+// abortingStorage simulates a backend like ocistorage that aborts a
+// multipart upload and returns as soon as one part fails, without draining
+// the rest of its PutReader input.
+type abortingStorage struct {
+	*fakeStorage
+	readBeforeAbort int
+}
+
+func (f *abortingStorage) PutReader(path string, reader io.Reader) error {
+	io.CopyN(ioutil.Discard, reader, int64(f.readBeforeAbort))
+	return errors.New("abortingStorage: simulated part upload failure")
+}
+
+func TestEncStoragePutGetRoundTrip(t *testing.T) {
+	inner := newFakeStorage()
+	s := New(inner, staticKeyProvider{}, 16)
+
+	plaintext := bytes.Repeat([]byte("rocks-strata"), 1000)
+	if err := s.PutReader("obj", bytes.NewReader(plaintext)); err != nil {
+		t.Fatalf("PutReader: %v", err)
+	}
+
+	r, err := s.Get("obj")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("round trip mismatch: got %d bytes, want %d bytes", len(got), len(plaintext))
+	}
+}
+
+// TestEncStorageGetClosePartialReadDrainsUnderlyingReader guards against the
+// bug where stopping a Get reader early and calling Close skipped the
+// underlying storage's own corruption check, because decryptingReader.Close
+// closed the underlying reader without first reading it to EOF.
+func TestEncStorageGetClosePartialReadDrainsUnderlyingReader(t *testing.T) {
+	inner := newFakeStorage()
+	s := New(inner, staticKeyProvider{}, 16)
+
+	plaintext := bytes.Repeat([]byte("rocks-strata"), 1000)
+	if err := s.PutReader("obj", bytes.NewReader(plaintext)); err != nil {
+		t.Fatalf("PutReader: %v", err)
+	}
+
+	r, err := s.Get("obj")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	// Read only a small prefix, then close without reaching EOF.
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatalf("ReadFull: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close should drain the rest of the object and succeed, got: %v", err)
+	}
+}
+
+// TestEncStoragePutReaderUnblocksOnInnerFailure checks that when the
+// underlying storage's PutReader returns early without draining its input
+// (as ocistorage does when it aborts a failed multipart upload), PutReader
+// returns that storage's error promptly. It does not observe whether the
+// encryptFrames goroutine feeding the underlying storage has exited.
+func TestEncStoragePutReaderUnblocksOnInnerFailure(t *testing.T) {
+	inner := &abortingStorage{fakeStorage: newFakeStorage(), readBeforeAbort: 4}
+	s := New(inner, staticKeyProvider{}, 16)
+
+	plaintext := bytes.Repeat([]byte("rocks-strata"), 10000)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.PutReader("obj", bytes.NewReader(plaintext))
+	}()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("expected PutReader to surface the inner storage's error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("PutReader deadlocked instead of returning the inner storage's error")
+	}
+}
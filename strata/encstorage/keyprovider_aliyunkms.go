@@ -0,0 +1,69 @@
+package encstorage
+
+import (
+	"encoding/base64"
+
+	"github.com/denverdino/aliyungo/kms"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func init() {
+	Register("aliyunkms", newAliyunKMSKeyProviderFromConfig)
+}
+
+// aliyunKMSConfig is the shape of the "config" field of a
+// --encryption.config document with "type: aliyunkms".
+type aliyunKMSConfig struct {
+	Region          string `yaml:"region"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	AccessKeySecret string `yaml:"access_key_secret"`
+	KeyID           string `yaml:"key_id"`
+}
+
+func newAliyunKMSKeyProviderFromConfig(data []byte) (KeyProvider, error) {
+	var cfg aliyunKMSConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return NewAliyunKMSKeyProvider(cfg.Region, cfg.AccessKeyID, cfg.AccessKeySecret, cfg.KeyID), nil
+}
+
+// AliyunKMSKeyProvider wraps data keys using an Aliyun KMS customer master
+// key, with the same region/access-key pair convention as ossstorage.
+type AliyunKMSKeyProvider struct {
+	client *kms.Client
+	keyID  string
+}
+
+// NewAliyunKMSKeyProvider builds a KeyProvider backed by the Aliyun KMS key
+// keyID.
+func NewAliyunKMSKeyProvider(region string, accessKeyID string, accessKeySecret string, keyID string) *AliyunKMSKeyProvider {
+	return &AliyunKMSKeyProvider{
+		client: kms.NewClient(region, accessKeyID, accessKeySecret),
+		keyID:  keyID,
+	}
+}
+
+// WrapKey calls Aliyun KMS's Encrypt action on dek.
+func (p *AliyunKMSKeyProvider) WrapKey(dek []byte) ([]byte, error) {
+	resp, err := p.client.Encrypt(kms.EncryptArgs{
+		KeyId:     p.keyID,
+		Plaintext: base64.StdEncoding.EncodeToString(dek),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.CiphertextBlob)
+}
+
+// UnwrapKey calls Aliyun KMS's Decrypt action on a key previously wrapped by
+// WrapKey.
+func (p *AliyunKMSKeyProvider) UnwrapKey(wrapped []byte) ([]byte, error) {
+	resp, err := p.client.Decrypt(kms.DecryptArgs{
+		CiphertextBlob: base64.StdEncoding.EncodeToString(wrapped),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Plaintext)
+}
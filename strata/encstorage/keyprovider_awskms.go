@@ -0,0 +1,61 @@
+package encstorage
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func init() {
+	Register("awskms", newAWSKMSKeyProviderFromConfig)
+}
+
+// awsKMSConfig is the shape of the "config" field of a --encryption.config
+// document with "type: awskms".
+type awsKMSConfig struct {
+	Region string `yaml:"region"`
+	KeyID  string `yaml:"key_id"`
+}
+
+func newAWSKMSKeyProviderFromConfig(data []byte) (KeyProvider, error) {
+	var cfg awsKMSConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return NewAWSKMSKeyProvider(cfg.Region, cfg.KeyID)
+}
+
+// AWSKMSKeyProvider wraps data keys using an AWS KMS customer master key.
+type AWSKMSKeyProvider struct {
+	svc   *kms.KMS
+	keyID string
+}
+
+// NewAWSKMSKeyProvider builds a KeyProvider backed by the AWS KMS key keyID
+// (a key ID, ARN or alias) in region, using the default AWS credential chain.
+func NewAWSKMSKeyProvider(region string, keyID string) (*AWSKMSKeyProvider, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return nil, err
+	}
+	return &AWSKMSKeyProvider{svc: kms.New(sess), keyID: keyID}, nil
+}
+
+// WrapKey calls kms:Encrypt on dek.
+func (p *AWSKMSKeyProvider) WrapKey(dek []byte) ([]byte, error) {
+	out, err := p.svc.Encrypt(&kms.EncryptInput{KeyId: aws.String(p.keyID), Plaintext: dek})
+	if err != nil {
+		return nil, err
+	}
+	return out.CiphertextBlob, nil
+}
+
+// UnwrapKey calls kms:Decrypt on a key previously wrapped by WrapKey.
+func (p *AWSKMSKeyProvider) UnwrapKey(wrapped []byte) ([]byte, error) {
+	out, err := p.svc.Decrypt(&kms.DecryptInput{CiphertextBlob: wrapped})
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
@@ -0,0 +1,70 @@
+package encstorage
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io/ioutil"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func init() {
+	Register("keyfile", newStaticKeyProviderFromConfig)
+}
+
+// staticConfig is the shape of the "config" field of a --encryption.config
+// document with "type: keyfile".
+type staticConfig struct {
+	Path string `yaml:"path"`
+}
+
+func newStaticKeyProviderFromConfig(data []byte) (KeyProvider, error) {
+	var cfg staticConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return NewStaticKeyProvider(cfg.Path)
+}
+
+// StaticKeyProvider wraps data keys with a single AES-256-GCM key read from a
+// local keyfile. It is meant for development and for deployments that manage
+// their own key rotation outside of a KMS.
+type StaticKeyProvider struct {
+	kek cipher.AEAD
+}
+
+// NewStaticKeyProvider reads a 32-byte AES-256 key from keyfilePath.
+func NewStaticKeyProvider(keyfilePath string) (*StaticKeyProvider, error) {
+	key, err := ioutil.ReadFile(keyfilePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != dekSize {
+		return nil, errors.New("encstorage: static keyfile must contain exactly 32 bytes")
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &StaticKeyProvider{kek: gcm}, nil
+}
+
+// WrapKey encrypts dek with the keyfile's AES-256-GCM key.
+func (p *StaticKeyProvider) WrapKey(dek []byte) ([]byte, error) {
+	nonce := make([]byte, p.kek.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return append(nonce, p.kek.Seal(nil, nonce, dek, nil)...), nil
+}
+
+// UnwrapKey decrypts a key previously wrapped by WrapKey.
+func (p *StaticKeyProvider) UnwrapKey(wrapped []byte) ([]byte, error) {
+	nonceSize := p.kek.NonceSize()
+	if len(wrapped) < nonceSize {
+		return nil, errors.New("encstorage: wrapped key is too short")
+	}
+	nonce, ciphertext := wrapped[:nonceSize], wrapped[nonceSize:]
+	return p.kek.Open(nil, nonce, ciphertext, nil)
+}
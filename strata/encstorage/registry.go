@@ -0,0 +1,40 @@
+package encstorage
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Factory builds a KeyProvider from a backend-specific config, which is the
+// raw bytes under a --encryption.config document's top-level "config" field.
+type Factory func(config []byte) (KeyProvider, error)
+
+var (
+	mu        sync.Mutex
+	factories = make(map[string]Factory)
+)
+
+// Register makes a KeyProvider available under the given type name. Register
+// is meant to be called from a provider's init(), the same way
+// strata/storage.Register lets storage backends register themselves.
+// Register panics if called twice for the same name.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, exists := factories[name]; exists {
+		panic(fmt.Sprintf("encstorage: Register called twice for key provider %q", name))
+	}
+	factories[name] = factory
+}
+
+// newKeyProvider builds a KeyProvider using the factory registered under
+// name, passing it the raw backend-specific config bytes.
+func newKeyProvider(name string, config []byte) (KeyProvider, error) {
+	mu.Lock()
+	factory, ok := factories[name]
+	mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("encstorage: no key provider registered under type %q", name)
+	}
+	return factory(config)
+}
@@ -0,0 +1,75 @@
+package ocistorage
+
+import (
+	"fmt"
+
+	"github.com/facebookgo/rocks-strata/strata"
+	"github.com/facebookgo/rocks-strata/strata/storage"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/common/auth"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func init() {
+	storage.Register("oci", newFromConfig)
+}
+
+// config is the shape of the "config" field of a --objstore.config-file
+// document with "type: oci". AuthMode mirrors how rclone's
+// oracleobjectstorage backend picks between instance principals, resource
+// principals, and a config file.
+type config struct {
+	Namespace     string `yaml:"namespace"`
+	CompartmentID string `yaml:"compartment_id"`
+	Bucket        string `yaml:"bucket"`
+	Prefix        string `yaml:"prefix"`
+	Region        string `yaml:"region"`
+	ChunkSize     int64  `yaml:"chunk_size"`
+	AuthMode      string `yaml:"auth_mode"`
+	ConfigFile    string `yaml:"config_file"`
+	ConfigProfile string `yaml:"config_profile"`
+}
+
+func newFromConfig(data []byte) (strata.Storage, error) {
+	var cfg config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	configProvider, err := authProvider(cfg.AuthMode, cfg.ConfigFile, cfg.ConfigProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewOCIStorage(Options{
+		Namespace:      cfg.Namespace,
+		CompartmentID:  cfg.CompartmentID,
+		Bucket:         cfg.Bucket,
+		Prefix:         cfg.Prefix,
+		Region:         cfg.Region,
+		ChunkSize:      cfg.ChunkSize,
+		ConfigProvider: configProvider,
+	})
+}
+
+// authProvider picks the credential source named by mode, the same three
+// choices rclone's oracleobjectstorage backend offers: a config file, an
+// instance principal, or a resource principal.
+func authProvider(mode string, configFile string, configProfile string) (common.ConfigurationProvider, error) {
+	switch mode {
+	case "instance-principal":
+		return auth.InstancePrincipalConfigurationProvider()
+	case "resource-principal":
+		return auth.ResourcePrincipalConfigurationProvider()
+	case "", "config-file":
+		if configFile == "" {
+			return common.DefaultConfigProvider(), nil
+		}
+		if configProfile == "" {
+			configProfile = "DEFAULT"
+		}
+		return common.ConfigurationProviderFromFileWithProfile(configFile, configProfile, "")
+	default:
+		return nil, fmt.Errorf("ocistorage: unknown auth_mode %q", mode)
+	}
+}
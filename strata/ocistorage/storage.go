@@ -0,0 +1,357 @@
+// Package ocistorage implements strata.Storage against Oracle Cloud
+// Infrastructure Object Storage using the official
+// github.com/oracle/oci-go-sdk/v65/objectstorage client.
+package ocistorage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/facebookgo/rocks-strata/strata"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/objectstorage"
+)
+
+// DefaultChunkSize is the part size PutReader uses for multipart uploads
+// when OCIStorage is constructed with a chunkSize of zero.
+const DefaultChunkSize = 10 * 1024 * 1024
+
+// Options configures NewOCIStorage.
+type Options struct {
+	Namespace     string
+	CompartmentID string
+	Bucket        string
+	Prefix        string
+	Region        string
+	// ChunkSize is the part size used for PutReader's multipart uploads.
+	// Defaults to DefaultChunkSize.
+	ChunkSize int64
+	// ConfigProvider supplies the credentials and tenancy/region metadata the
+	// client authenticates with: an instance principal, a resource
+	// principal, or a config-file provider all satisfy this.
+	ConfigProvider common.ConfigurationProvider
+}
+
+// OCIStorage implements the strata.Storage interface using OCI Object
+// Storage as its storage backing.
+type OCIStorage struct {
+	client        objectstorage.ObjectStorageClient
+	namespace     string
+	compartmentID string
+	bucket        string
+	prefix        string
+	chunkSize     int64
+	ctx           context.Context
+}
+
+// NewOCIStorage initializes the OCIStorage with the given Options, creating
+// the bucket if it does not already exist.
+func NewOCIStorage(opts Options) (*OCIStorage, error) {
+	client, err := objectstorage.NewObjectStorageClientWithConfigurationProvider(opts.ConfigProvider)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Region != "" {
+		client.SetRegion(opts.Region)
+	}
+
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	s := &OCIStorage{
+		client:        client,
+		namespace:     opts.Namespace,
+		compartmentID: opts.CompartmentID,
+		bucket:        opts.Bucket,
+		prefix:        opts.Prefix,
+		chunkSize:     chunkSize,
+		ctx:           context.Background(),
+	}
+
+	_, err = client.GetBucket(s.ctx, objectstorage.GetBucketRequest{
+		NamespaceName: common.String(opts.Namespace),
+		BucketName:    common.String(opts.Bucket),
+	})
+	if err != nil {
+		if !isNotFound(err) {
+			return nil, err
+		}
+		_, err = client.CreateBucket(s.ctx, objectstorage.CreateBucketRequest{
+			NamespaceName: common.String(opts.Namespace),
+			CreateBucketDetails: objectstorage.CreateBucketDetails{
+				Name:          common.String(opts.Bucket),
+				CompartmentId: common.String(opts.CompartmentID),
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *OCIStorage) addPrefix(path string) string {
+	return s.prefix + "/" + path
+}
+
+func (s *OCIStorage) removePrefix(path string) string {
+	return path[len(s.prefix)+1:]
+}
+
+// Get returns a reader to the specified OCI Object Storage path, checksummed
+// against the object's opc-content-md5 header, falling back to its ETag for
+// objects that don't carry one (e.g. ones written by a multipart upload).
+func (s *OCIStorage) Get(path string) (io.ReadCloser, error) {
+	ociPath := s.addPrefix(path)
+	resp, err := s.client.GetObject(s.ctx, objectstorage.GetObjectRequest{
+		NamespaceName: common.String(s.namespace),
+		BucketName:    common.String(s.bucket),
+		ObjectName:    common.String(ociPath),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, strata.ErrNotFound(path)
+		}
+		return nil, err
+	}
+
+	checksum, err := contentChecksum(resp.ContentMd5, resp.ETag)
+	if err != nil {
+		return nil, err
+	}
+	return strata.NewChecksummingReader(resp.Content, checksum), nil
+}
+
+// contentChecksum decodes the MD5 checksum that strata.NewChecksummingReader
+// expects from an object's opc-content-md5 header, falling back to its ETag
+// when the object has no content MD5 (as is the case for objects that were
+// written with a multipart upload).
+func contentChecksum(contentMd5 *string, etag *string) ([]byte, error) {
+	if contentMd5 != nil && *contentMd5 != "" {
+		return base64.StdEncoding.DecodeString(*contentMd5)
+	}
+	if etag != nil && *etag != "" {
+		return hex.DecodeString(strings.Trim(*etag, "\""))
+	}
+	return nil, errors.New("ocistorage: object has neither an opc-content-md5 nor an ETag")
+}
+
+// Put places the byte slice at the given path in OCI Object Storage.
+func (s *OCIStorage) Put(path string, data []byte) error {
+	return s.putObject(s.addPrefix(path), data)
+}
+
+func (s *OCIStorage) putObject(ociPath string, data []byte) error {
+	_, err := s.client.PutObject(s.ctx, objectstorage.PutObjectRequest{
+		NamespaceName: common.String(s.namespace),
+		BucketName:    common.String(s.bucket),
+		ObjectName:    common.String(ociPath),
+		ContentLength: common.Int64(int64(len(data))),
+		PutObjectBody: ioutil.NopCloser(bytes.NewReader(data)),
+	})
+	return err
+}
+
+// PutReader consumes the given reader and stores it at the specified path in
+// OCI Object Storage. Objects larger than one chunk are streamed up with a
+// multipart upload so PutReader never buffers the whole object in memory;
+// smaller ones fall back to a plain PutObject.
+func (s *OCIStorage) PutReader(path string, reader io.Reader) error {
+	ociPath := s.addPrefix(path)
+
+	buf := make([]byte, s.chunkSize)
+	n, err := io.ReadFull(reader, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return s.putObject(ociPath, buf[:n])
+	}
+
+	return s.putMultipart(s.client, ociPath, append([]byte(nil), buf[:n]...), reader)
+}
+
+// multipartClient is the subset of objectstorage.ObjectStorageClient that
+// putMultipart drives. It exists so that the chunking and error/abort logic
+// can be tested against a fake, without talking to OCI.
+type multipartClient interface {
+	CreateMultipartUpload(ctx context.Context, request objectstorage.CreateMultipartUploadRequest) (objectstorage.CreateMultipartUploadResponse, error)
+	UploadPart(ctx context.Context, request objectstorage.UploadPartRequest) (objectstorage.UploadPartResponse, error)
+	CommitMultipartUpload(ctx context.Context, request objectstorage.CommitMultipartUploadRequest) (objectstorage.CommitMultipartUploadResponse, error)
+	AbortMultipartUpload(ctx context.Context, request objectstorage.AbortMultipartUploadRequest) (objectstorage.AbortMultipartUploadResponse, error)
+}
+
+// putMultipart uploads first, followed by the rest of reader in s.chunkSize
+// parts, as a multipart upload against client.
+func (s *OCIStorage) putMultipart(client multipartClient, ociPath string, first []byte, reader io.Reader) error {
+	uploadID, err := s.createMultipartUpload(client, ociPath)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, s.chunkSize)
+	var parts []objectstorage.CommitMultipartUploadPartDetails
+	partNum := 1
+	chunk := first
+	for {
+		etag, err := s.uploadPart(client, ociPath, uploadID, partNum, chunk)
+		if err != nil {
+			s.abortMultipartUpload(client, ociPath, uploadID)
+			return err
+		}
+		parts = append(parts, objectstorage.CommitMultipartUploadPartDetails{
+			PartNum: common.Int(partNum),
+			Etag:    etag,
+		})
+
+		n, readErr := io.ReadFull(reader, buf)
+		if readErr != nil && readErr != io.ErrUnexpectedEOF && readErr != io.EOF {
+			s.abortMultipartUpload(client, ociPath, uploadID)
+			return readErr
+		}
+		if n == 0 {
+			break
+		}
+		partNum++
+		chunk = append(chunk[:0], buf[:n]...)
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			etag, err := s.uploadPart(client, ociPath, uploadID, partNum, chunk)
+			if err != nil {
+				s.abortMultipartUpload(client, ociPath, uploadID)
+				return err
+			}
+			parts = append(parts, objectstorage.CommitMultipartUploadPartDetails{
+				PartNum: common.Int(partNum),
+				Etag:    etag,
+			})
+			break
+		}
+	}
+
+	return s.commitMultipartUpload(client, ociPath, uploadID, parts)
+}
+
+func (s *OCIStorage) createMultipartUpload(client multipartClient, ociPath string) (*string, error) {
+	resp, err := client.CreateMultipartUpload(s.ctx, objectstorage.CreateMultipartUploadRequest{
+		NamespaceName: common.String(s.namespace),
+		BucketName:    common.String(s.bucket),
+		CreateMultipartUploadDetails: objectstorage.CreateMultipartUploadDetails{
+			Object: common.String(ociPath),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.MultipartUpload.UploadId, nil
+}
+
+func (s *OCIStorage) uploadPart(client multipartClient, ociPath string, uploadID *string, partNum int, data []byte) (*string, error) {
+	resp, err := client.UploadPart(s.ctx, objectstorage.UploadPartRequest{
+		NamespaceName:  common.String(s.namespace),
+		BucketName:     common.String(s.bucket),
+		ObjectName:     common.String(ociPath),
+		UploadId:       uploadID,
+		UploadPartNum:  common.Int(partNum),
+		ContentLength:  common.Int64(int64(len(data))),
+		UploadPartBody: ioutil.NopCloser(bytes.NewReader(data)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.ETag, nil
+}
+
+func (s *OCIStorage) commitMultipartUpload(client multipartClient, ociPath string, uploadID *string, parts []objectstorage.CommitMultipartUploadPartDetails) error {
+	_, err := client.CommitMultipartUpload(s.ctx, objectstorage.CommitMultipartUploadRequest{
+		NamespaceName: common.String(s.namespace),
+		BucketName:    common.String(s.bucket),
+		ObjectName:    common.String(ociPath),
+		UploadId:      uploadID,
+		CommitMultipartUploadDetails: objectstorage.CommitMultipartUploadDetails{
+			PartsToCommit: parts,
+		},
+	})
+	return err
+}
+
+func (s *OCIStorage) abortMultipartUpload(client multipartClient, ociPath string, uploadID *string) {
+	client.AbortMultipartUpload(s.ctx, objectstorage.AbortMultipartUploadRequest{
+		NamespaceName: common.String(s.namespace),
+		BucketName:    common.String(s.bucket),
+		ObjectName:    common.String(ociPath),
+		UploadId:      uploadID,
+	})
+}
+
+// Delete removes the object at the given OCI Object Storage path.
+func (s *OCIStorage) Delete(path string) error {
+	_, err := s.client.DeleteObject(s.ctx, objectstorage.DeleteObjectRequest{
+		NamespaceName: common.String(s.namespace),
+		BucketName:    common.String(s.bucket),
+		ObjectName:    common.String(s.addPrefix(path)),
+	})
+	return err
+}
+
+// List returns a list of objects (up to maxSize) with the given prefix,
+// paginating through ListObjects with the start/nextStartWith cursor.
+func (s *OCIStorage) List(prefix string, maxSize int) ([]string, error) {
+	ociPrefix := s.addPrefix(prefix)
+	start := ""
+
+	items := make([]string, 0, 1000)
+	for maxSize > 0 {
+		limit := 1000
+		if maxSize < limit {
+			limit = maxSize
+		}
+		req := objectstorage.ListObjectsRequest{
+			NamespaceName: common.String(s.namespace),
+			BucketName:    common.String(s.bucket),
+			Prefix:        common.String(ociPrefix),
+			Limit:         common.Int(limit),
+		}
+		if start != "" {
+			req.Start = common.String(start)
+		}
+		resp, err := s.client.ListObjects(s.ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		maxSize -= limit
+
+		for _, object := range resp.Objects {
+			items = append(items, s.removePrefix(*object.Name))
+		}
+		if resp.NextStartWith == nil || *resp.NextStartWith == "" {
+			break
+		}
+		start = *resp.NextStartWith
+	}
+
+	return items, nil
+}
+
+// Lock is not implemented
+func (s *OCIStorage) Lock(path string) error {
+	return nil
+}
+
+// Unlock is not implemented
+func (s *OCIStorage) Unlock(path string) error {
+	return nil
+}
+
+func isNotFound(err error) bool {
+	svcErr, ok := common.IsServiceError(err)
+	return ok && svcErr.GetHTTPStatusCode() == 404
+}
@@ -0,0 +1,91 @@
+package ocistorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/oracle/oci-go-sdk/v65/objectstorage"
+)
+
+// fakeMultipartClient stands in for objectstorage.ObjectStorageClient so that
+// putMultipart's chunking and error/abort behavior can be exercised without
+// talking to OCI.
+type fakeMultipartClient struct {
+	mu        sync.Mutex
+	uploadErr error
+	aborted   bool
+	committed []objectstorage.CommitMultipartUploadPartDetails
+}
+
+func (f *fakeMultipartClient) CreateMultipartUpload(ctx context.Context, request objectstorage.CreateMultipartUploadRequest) (objectstorage.CreateMultipartUploadResponse, error) {
+	id := "upload-1"
+	return objectstorage.CreateMultipartUploadResponse{
+		MultipartUpload: objectstorage.MultipartUpload{UploadId: &id},
+	}, nil
+}
+
+func (f *fakeMultipartClient) UploadPart(ctx context.Context, request objectstorage.UploadPartRequest) (objectstorage.UploadPartResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.uploadErr != nil {
+		return objectstorage.UploadPartResponse{}, f.uploadErr
+	}
+	etag := fmt.Sprintf("etag-%d", *request.UploadPartNum)
+	return objectstorage.UploadPartResponse{ETag: &etag}, nil
+}
+
+func (f *fakeMultipartClient) CommitMultipartUpload(ctx context.Context, request objectstorage.CommitMultipartUploadRequest) (objectstorage.CommitMultipartUploadResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.committed = request.CommitMultipartUploadDetails.PartsToCommit
+	return objectstorage.CommitMultipartUploadResponse{}, nil
+}
+
+func (f *fakeMultipartClient) AbortMultipartUpload(ctx context.Context, request objectstorage.AbortMultipartUploadRequest) (objectstorage.AbortMultipartUploadResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.aborted = true
+	return objectstorage.AbortMultipartUploadResponse{}, nil
+}
+
+func TestPutMultipartSucceeds(t *testing.T) {
+	s := &OCIStorage{chunkSize: 4, ctx: context.Background(), namespace: "ns", bucket: "b"}
+	client := &fakeMultipartClient{}
+
+	rest := bytes.NewReader([]byte("bbbbcccc"))
+	if err := s.putMultipart(client, "obj", []byte("aaaa"), rest); err != nil {
+		t.Fatalf("putMultipart: %v", err)
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if len(client.committed) != 3 {
+		t.Fatalf("committed %d parts, want 3", len(client.committed))
+	}
+	if client.aborted {
+		t.Fatal("putMultipart should not call Abort on success")
+	}
+}
+
+// TestPutMultipartAbortsOnUploadPartFailure guards the multipart upload's
+// error path: a failed part upload must abort the upload and return the
+// error instead of silently continuing.
+func TestPutMultipartAbortsOnUploadPartFailure(t *testing.T) {
+	s := &OCIStorage{chunkSize: 4, ctx: context.Background(), namespace: "ns", bucket: "b"}
+	client := &fakeMultipartClient{uploadErr: errors.New("service unavailable")}
+
+	err := s.putMultipart(client, "obj", []byte("aaaa"), bytes.NewReader([]byte("bbbb")))
+	if err == nil {
+		t.Fatal("expected putMultipart to return the part upload error")
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if !client.aborted {
+		t.Fatal("expected putMultipart to call AbortMultipartUpload after a part failed")
+	}
+}
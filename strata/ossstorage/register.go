@@ -0,0 +1,61 @@
+package ossstorage
+
+import (
+	"errors"
+	"os"
+
+	sdk "github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/facebookgo/rocks-strata/strata"
+	"github.com/facebookgo/rocks-strata/strata/storage"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func init() {
+	storage.Register("oss", newFromConfig)
+}
+
+// config is the shape of the "config" field of a --objstore.config-file
+// document with "type: oss".
+type config struct {
+	Endpoint      string `yaml:"endpoint"`
+	Bucket        string `yaml:"bucket"`
+	Prefix        string `yaml:"prefix"`
+	ACL           string `yaml:"acl"`
+	RoleArn       string `yaml:"role_arn"`
+	PartSize      int64  `yaml:"part_size"`
+	CheckpointDir string `yaml:"checkpoint_dir"`
+	Retries       int    `yaml:"retries"`
+}
+
+// newFromConfig builds an OSSStorage from a config file's "config" field.
+// Credentials are still read from the environment rather than the config
+// file, the same way lrossdriver.DriverFactory.Driver does, so that access
+// keys never end up on disk next to the bucket config. OSS_STS_TOKEN is
+// optional and, when set, is used alongside the long-lived access key as an
+// STS/RAM role session assumed from RoleArn.
+func newFromConfig(data []byte) (strata.Storage, error) {
+	var cfg config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	accessKey := os.Getenv("OSS_ACCESS_KEY_ID")
+	secretKey := os.Getenv("OSS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return nil, errors.New("environment variables OSS_ACCESS_KEY_ID and OSS_SECRET_ACCESS_KEY must be set")
+	}
+
+	return NewOSSStorage(Options{
+		Endpoint:        cfg.Endpoint,
+		AccessKeyID:     accessKey,
+		AccessKeySecret: secretKey,
+		STSToken:        os.Getenv("OSS_STS_TOKEN"),
+		RoleArn:         cfg.RoleArn,
+		BucketName:      cfg.Bucket,
+		Prefix:          cfg.Prefix,
+		BucketACL:       sdk.ACLType(cfg.ACL),
+		PartSize:        cfg.PartSize,
+		CheckpointDir:   cfg.CheckpointDir,
+		Retries:         cfg.Retries,
+	})
+}
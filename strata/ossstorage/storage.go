@@ -1,22 +1,140 @@
+// Package ossstorage implements strata.Storage on top of the official
+// github.com/aliyun/aliyun-oss-go-sdk/oss client. It replaces the v1 driver
+// (built on the abandoned denverdino/aliyungo/oss and PinIdea/oss-aliyun-go
+// clients, kept around under the "ossv1" build tag) with resumable
+// uploads/downloads, server-side copy, STS/RAM credentials and retries on
+// transient errors.
 package ossstorage
 
 import (
+	"bytes"
+	"crypto/md5"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/denverdino/aliyungo/oss"
+	sdk "github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/facebookgo/rocks-strata/strata"
 )
 
+const (
+	// DefaultPartSize is the part size UploadFile/DownloadFile use for
+	// resumable multipart transfers when Options.PartSize is zero.
+	DefaultPartSize = 10 * 1024 * 1024
+	// MinPartSize is the smallest part size OSS multipart uploads accept.
+	// Any configured PartSize below this is rounded up.
+	MinPartSize = 100 * 1024
+	// DefaultRetries is the number of retry attempts withRetry makes on a
+	// retryable error when Options.Retries is zero.
+	DefaultRetries = 5
+	// defaultUploadRoutines bounds how many parts UploadFile/DownloadFile
+	// transfer concurrently.
+	defaultUploadRoutines = 3
+)
+
+// Options configures NewOSSStorage.
+type Options struct {
+	// Endpoint is the OSS endpoint, e.g. "oss-cn-hangzhou.aliyuncs.com".
+	Endpoint string
+	// AccessKeyID/AccessKeySecret are long-lived credentials. Leave these
+	// set even when STSToken is used: OSS treats them as the AccessKeyID/
+	// AccessKeySecret of the STS token in that case.
+	AccessKeyID     string
+	AccessKeySecret string
+	// STSToken is an optional security token for STS/RAM role credentials,
+	// as obtained from AssumeRole for RoleArn.
+	STSToken string
+	// RoleArn is informational: the RAM role that STSToken was assumed
+	// from, recorded so operators can tell which credentials a driver is
+	// running under. rocks-strata does not call AssumeRole itself; operators
+	// or their surrounding tooling are expected to supply an already-valid
+	// STSToken for RoleArn.
+	RoleArn string
+
+	BucketName string
+	Prefix     string
+	BucketACL  sdk.ACLType
+
+	// PartSize is the part size used for multipart PutReader uploads and for
+	// resumable Get downloads. Defaults to DefaultPartSize.
+	PartSize int64
+	// CheckpointDir holds the resumable-transfer checkpoint files that let an
+	// interrupted PutReader/Get resume instead of starting over. Defaults to
+	// a directory under os.TempDir().
+	CheckpointDir string
+	// Retries is the number of times to retry an operation that fails with a
+	// retryable (5xx or network) error. Defaults to DefaultRetries.
+	Retries int
+}
+
 // OSSStorage implements the strata.Storage interface using OSS as its storage backing
 type OSSStorage struct {
-	oss    *oss.Client
-	bucket *oss.Bucket
-	region oss.Region
-	prefix string
+	client        *sdk.Client
+	bucket        *sdk.Bucket
+	prefix        string
+	partSize      int64
+	checkpointDir string
+	retries       int
+}
+
+// NewOSSStorage initializes the OSSStorage with the given Options.
+func NewOSSStorage(opts Options) (*OSSStorage, error) {
+	var clientOptions []sdk.ClientOption
+	if opts.STSToken != "" {
+		clientOptions = append(clientOptions, sdk.SecurityToken(opts.STSToken))
+	}
+	client, err := sdk.New(opts.Endpoint, opts.AccessKeyID, opts.AccessKeySecret, clientOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := client.IsBucketExist(opts.BucketName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := client.CreateBucket(opts.BucketName, sdk.ACL(opts.BucketACL)); err != nil {
+			return nil, err
+		}
+	}
+	bucket, err := client.Bucket(opts.BucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	partSize := opts.PartSize
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	} else if partSize < MinPartSize {
+		partSize = MinPartSize
+	}
+	retries := opts.Retries
+	if retries <= 0 {
+		retries = DefaultRetries
+	}
+	checkpointDir := opts.CheckpointDir
+	if checkpointDir == "" {
+		checkpointDir = filepath.Join(os.TempDir(), "strata-ossstorage-checkpoints")
+	}
+	if err := os.MkdirAll(checkpointDir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &OSSStorage{
+		client:        client,
+		bucket:        bucket,
+		prefix:        opts.Prefix,
+		partSize:      partSize,
+		checkpointDir: checkpointDir,
+		retries:       retries,
+	}, nil
 }
 
 func (s *OSSStorage) addPrefix(path string) string {
@@ -27,111 +145,199 @@ func (s *OSSStorage) removePrefix(path string) string {
 	return path[len(s.prefix)+1:]
 }
 
-// NewOSSStorage initializes the OSSStorage with required OSS arguments
-func NewOSSStorage(bucketName string, prefix string, region oss.Region, internal bool, accessKeyID string, accessKeySecret string, secure bool, bucketACL oss.ACL) (*OSSStorage, error) {
-	ossclient := oss.NewOSSClient(region, false, accessKeyID, accessKeySecret, secure)
-	bucket := ossclient.Bucket(bucketName)
+// checkpointPath returns a filename, unique to path, that UploadFile/
+// DownloadFile can use to track resumable-transfer progress.
+func (s *OSSStorage) checkpointPath(path string) string {
+	return filepath.Join(s.checkpointDir, strings.Replace(path, "/", "_", -1)+".cp")
+}
 
-	_, err := bucket.List("", "/", "", 1)
+// Get returns a reader to the specified OSS path. Get downloads the object to
+// a local temporary file with DownloadFile, so that a network blip partway
+// through a large restore resumes from its checkpoint instead of starting
+// over; the returned ReadCloser removes the temporary file on Close. As
+// before, the reader is checksummed against the object's ETag.
+func (s *OSSStorage) Get(path string) (io.ReadCloser, error) {
+	ossPath := s.addPrefix(path)
 
+	checksum, err := s.etag(ossPath)
 	if err != nil {
-		err = bucket.PutBucket(bucketACL)
-		if err != nil {
-			return nil, err
-		}
+		return nil, err
 	}
 
-	return &OSSStorage{
-		oss:    ossclient,
-		bucket: bucket,
-		region: region,
-		prefix: prefix,
-	}, nil
+	tmp, err := ioutil.TempFile("", "strata-ossstorage-get-")
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+
+	err = s.withRetry(func() error {
+		return s.bucket.DownloadFile(ossPath, tmpPath, s.partSize,
+			sdk.Routines(defaultUploadRoutines), sdk.Checkpoint(true, s.checkpointPath(path)))
+	})
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+
+	f, err := os.Open(tmpPath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	return strata.NewChecksummingReader(&tempFile{File: f, path: tmpPath}, checksum), nil
 }
 
-// Get returns a reader to the specified OSS path.
-func (s *OSSStorage) Get(path string) (io.ReadCloser, error) {
-	path = s.addPrefix(path)
-	resp, err := s.bucket.GetResponse(path)
-	if resp == nil || err != nil {
-		if err.Error() == "The specified key does not exist." {
-			err = strata.ErrNotFound(path)
+// etag fetches the object's ETag and decodes it as the MD5 checksum that
+// strata.NewChecksummingReader expects.
+func (s *OSSStorage) etag(ossPath string) ([]byte, error) {
+	var meta map[string][]string
+	err := s.withRetry(func() error {
+		var metaErr error
+		meta, metaErr = s.bucket.GetObjectDetailedMeta(ossPath)
+		return metaErr
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, strata.ErrNotFound(s.removePrefix(ossPath))
 		}
 		return nil, err
 	}
-	etag, found := resp.Header["Etag"]
-	if !found {
-		return nil, errors.New("No Etag header")
-	}
+	etag := meta["Etag"]
 	if len(etag) == 0 {
-		return nil, errors.New("Etag header is empty")
+		return nil, errors.New("no Etag header")
 	}
-	// Note: osstest does not require the trimming, but real OSS does
-	checksum, err := hex.DecodeString(strings.TrimSuffix(strings.TrimPrefix(etag[0], "\""), "\""))
-	if err != nil {
-		return nil, err
+	return hex.DecodeString(strings.Trim(etag[0], "\""))
+}
+
+// tempFile deletes its backing file once it is closed, so that Get's
+// temporary download doesn't leak into the OS temp directory.
+type tempFile struct {
+	*os.File
+	path string
+}
+
+func (t *tempFile) Close() error {
+	closeErr := t.File.Close()
+	if err := os.Remove(t.path); err != nil && closeErr == nil {
+		closeErr = err
 	}
-	return strata.NewChecksummingReader(resp.Body, checksum), nil
+	return closeErr
 }
 
-// Put places the byte slice at the given path in OSS.
-// Put also sends a checksum to protect against network corruption.
+// Put places the byte slice at the given path in OSS, then verifies the
+// upload against a locally computed MD5, the same way Get verifies a
+// download.
 func (s *OSSStorage) Put(path string, data []byte) error {
-	path = s.addPrefix(path)
-	err := s.bucket.Put(path, data, "application/octet-stream", oss.Private)
-	return err
+	ossPath := s.addPrefix(path)
+	sum := md5.Sum(data)
+	if err := s.withRetry(func() error {
+		return s.bucket.PutObject(ossPath, bytes.NewReader(data))
+	}); err != nil {
+		return err
+	}
+	return s.verifyUpload(ossPath, sum[:])
 }
 
-// PutReader consumes the given reader and stores it at the specified path in OSS.
-// A checksum is used to protect against network corruption.
+// PutReader consumes the given reader and stores it at the specified path in
+// OSS. PutReader spills reader to a local temporary file and hands it to
+// UploadFile, so that large SST files upload as a resumable, checkpointed
+// multipart transfer: an interrupted upload resumes from its checkpoint on
+// retry instead of re-sending parts that already landed. The upload is then
+// verified against the MD5 computed locally while spilling to the temporary
+// file, the same way Get verifies a download.
 func (s *OSSStorage) PutReader(path string, reader io.Reader) error {
-	// TODO(agf): OSS will send a checksum as a response after we do a PUT.
-	// We could compute our checksum on the fly by using an ChecksummingReader,
-	// and then compare the checksum to the one that OSS sends back. However,
-	// goamz does not give us access to the checksum that OSS sends back, so we
-	// need to load the data into memory and compute the checksum beforehand.
-	// Should fix this in goamz.
-	data, err := ioutil.ReadAll(reader)
+	tmp, err := ioutil.TempFile("", "strata-ossstorage-put-")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	hasher := md5.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hasher), reader); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	ossPath := s.addPrefix(path)
+	if err := s.withRetry(func() error {
+		return s.bucket.UploadFile(ossPath, tmpPath, s.partSize,
+			sdk.Routines(defaultUploadRoutines), sdk.Checkpoint(true, s.checkpointPath(path)))
+	}); err != nil {
+		return err
+	}
+	return s.verifyUpload(ossPath, hasher.Sum(nil))
+}
+
+// verifyUpload fetches ossPath's ETag and compares it against localMD5, the
+// same corruption check Get performs on download via etag().
+func (s *OSSStorage) verifyUpload(ossPath string, localMD5 []byte) error {
+	remoteMD5, err := s.etag(ossPath)
 	if err != nil {
 		return err
 	}
-	return s.Put(path, data)
+	if !bytes.Equal(remoteMD5, localMD5) {
+		return fmt.Errorf("ossstorage: uploaded object %s ETag %x does not match local MD5 %x", ossPath, remoteMD5, localMD5)
+	}
+	return nil
+}
+
+// Copy copies the object at src to dst within the same bucket using OSS's
+// server-side CopyObject, so that snapshot promotion/renaming doesn't have to
+// download and re-upload the data.
+//
+// Copy is not yet part of the strata.Storage interface upstream; callers that
+// know they are holding an *OSSStorage, or that type-assert for a
+// `Copy(string, string) error` method, can use it today.
+func (s *OSSStorage) Copy(src, dst string) error {
+	srcPath := s.addPrefix(src)
+	dstPath := s.addPrefix(dst)
+	return s.withRetry(func() error {
+		_, err := s.bucket.CopyObject(srcPath, dstPath)
+		return err
+	})
 }
 
 // Delete removes the object at the given OSS path
 func (s *OSSStorage) Delete(path string) error {
-	path = s.addPrefix(path)
-	err := s.bucket.Del(path)
-	return err
+	ossPath := s.addPrefix(path)
+	return s.withRetry(func() error {
+		return s.bucket.DeleteObject(ossPath)
+	})
 }
 
 // List returns a list of objects (up to maxSize) with the given prefix from OSS
 func (s *OSSStorage) List(prefix string, maxSize int) ([]string, error) {
-	prefix = s.addPrefix(prefix)
-	pathSeparator := ""
+	ossPrefix := s.addPrefix(prefix)
 	marker := ""
 
 	items := make([]string, 0, 1000)
 	for maxSize > 0 {
-		// Don't ask for more than 1000 keys at a time. This makes
-		// testing simpler because OSS will return at most 1000 keys even if you
-		// ask for more, but osstest will return more than 1000 keys if you ask
-		// for more. TODO(agf): Fix this behavior in osstest.
 		maxReqSize := 1000
 		if maxSize < 1000 {
 			maxReqSize = maxSize
 		}
-		contents, err := s.bucket.List(prefix, pathSeparator, marker, maxReqSize)
+		var result sdk.ListObjectsResult
+		err := s.withRetry(func() error {
+			var listErr error
+			result, listErr = s.bucket.ListObjects(
+				sdk.Prefix(ossPrefix), sdk.Marker(marker), sdk.MaxKeys(maxReqSize))
+			return listErr
+		})
 		if err != nil {
 			return nil, err
 		}
 		maxSize -= maxReqSize
 
-		for _, key := range contents.Contents {
-			items = append(items, s.removePrefix(key.Key))
+		for _, object := range result.Objects {
+			items = append(items, s.removePrefix(object.Key))
 		}
-		if contents.IsTruncated {
-			marker = s.addPrefix(items[len(items)-1])
+		if result.IsTruncated {
+			marker = result.NextMarker
 		} else {
 			break
 		}
@@ -149,3 +355,36 @@ func (s *OSSStorage) Lock(path string) error {
 func (s *OSSStorage) Unlock(path string) error {
 	return nil
 }
+
+// withRetry retries fn with exponential backoff when it fails with a
+// retryable (5xx or network) error, up to s.retries times.
+func (s *OSSStorage) withRetry(fn func() error) error {
+	var err error
+	backoff := 200 * time.Millisecond
+	for attempt := 0; attempt <= s.retries; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if attempt == s.retries || !isRetryable(err) {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	return err
+}
+
+func isRetryable(err error) bool {
+	if svcErr, ok := err.(sdk.ServiceError); ok {
+		return svcErr.StatusCode >= 500
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	return false
+}
+
+func isNotFound(err error) bool {
+	svcErr, ok := err.(sdk.ServiceError)
+	return ok && svcErr.Code == "NoSuchKey"
+}
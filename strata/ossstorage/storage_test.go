@@ -0,0 +1,83 @@
+package ossstorage
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+type fakeNetError struct{}
+
+func (fakeNetError) Error() string   { return "fake network error" }
+func (fakeNetError) Timeout() bool   { return true }
+func (fakeNetError) Temporary() bool { return true }
+
+var _ net.Error = fakeNetError{}
+
+func TestWithRetryRetriesOnNetError(t *testing.T) {
+	s := &OSSStorage{retries: 3}
+	attempts := 0
+	err := s.withRetry(func() error {
+		attempts++
+		if attempts < 3 {
+			return fakeNetError{}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withRetry: %v", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestWithRetryGivesUpAfterRetriesExhausted(t *testing.T) {
+	s := &OSSStorage{retries: 2}
+	attempts := 0
+	err := s.withRetry(func() error {
+		attempts++
+		return fakeNetError{}
+	})
+	if err == nil {
+		t.Fatal("expected withRetry to return the last error once retries are exhausted")
+	}
+	if attempts != 3 {
+		t.Fatalf("attempts = %d, want 3 (1 initial + 2 retries)", attempts)
+	}
+}
+
+func TestWithRetryDoesNotRetryNonRetryableError(t *testing.T) {
+	s := &OSSStorage{retries: 5}
+	attempts := 0
+	nonRetryable := errors.New("not retryable")
+	err := s.withRetry(func() error {
+		attempts++
+		return nonRetryable
+	})
+	if err != nonRetryable {
+		t.Fatalf("withRetry: got %v, want %v", err, nonRetryable)
+	}
+	if attempts != 1 {
+		t.Fatalf("attempts = %d, want 1", attempts)
+	}
+}
+
+func TestAddRemovePrefix(t *testing.T) {
+	s := &OSSStorage{prefix: "mongo"}
+	if got := s.addPrefix("backup/1"); got != "mongo/backup/1" {
+		t.Fatalf("addPrefix: got %q", got)
+	}
+	if got := s.removePrefix("mongo/backup/1"); got != "backup/1" {
+		t.Fatalf("removePrefix: got %q", got)
+	}
+}
+
+func TestCheckpointPath(t *testing.T) {
+	s := &OSSStorage{checkpointDir: "/tmp/checkpoints"}
+	got := s.checkpointPath("mongo/backup/1")
+	want := "/tmp/checkpoints/mongo_backup_1.cp"
+	if got != want {
+		t.Fatalf("checkpointPath: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,365 @@
+//go:build ossv1
+// +build ossv1
+
+// Package ossstorage (v1) is the legacy OSS driver built on the abandoned
+// denverdino/aliyungo/oss and PinIdea/oss-aliyun-go clients. It is kept
+// around, opted into with the "ossv1" build tag, only so that it can be
+// compared against or rolled back to during the migration to the v2 driver
+// (the default strata/ossstorage package) built on the official
+// aliyun-oss-go-sdk. New code should use the default package instead.
+package ossstorage
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/denverdino/aliyungo/oss"
+	"github.com/facebookgo/rocks-strata/strata"
+)
+
+const (
+	// DefaultChunkSize is the part size PutReader uses for multipart uploads
+	// when OSSStorage is constructed with a chunkSize of zero.
+	DefaultChunkSize = 10 * 1024 * 1024
+	// MinChunkSize is the smallest part size that OSS multipart uploads accept.
+	// Any configured chunk size below this is rounded up.
+	MinChunkSize = 5 * 1024 * 1024
+)
+
+// OSSStorage implements the strata.Storage interface using OSS as its storage backing
+type OSSStorage struct {
+	oss                  *oss.Client
+	bucket               *oss.Bucket
+	region               oss.Region
+	prefix               string
+	chunkSize            int64
+	multipartConcurrency int
+}
+
+func (s *OSSStorage) addPrefix(path string) string {
+	return s.prefix + "/" + path
+}
+
+func (s *OSSStorage) removePrefix(path string) string {
+	return path[len(s.prefix)+1:]
+}
+
+// NewOSSStorage initializes the OSSStorage with required OSS arguments
+func NewOSSStorage(bucketName string, prefix string, region oss.Region, internal bool, accessKeyID string, accessKeySecret string, secure bool, bucketACL oss.ACL, chunkSize int64, multipartConcurrency int) (*OSSStorage, error) {
+	ossclient := oss.NewOSSClient(region, false, accessKeyID, accessKeySecret, secure)
+	bucket := ossclient.Bucket(bucketName)
+
+	_, err := bucket.List("", "/", "", 1)
+
+	if err != nil {
+		err = bucket.PutBucket(bucketACL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	} else if chunkSize < MinChunkSize {
+		chunkSize = MinChunkSize
+	}
+	if multipartConcurrency <= 0 {
+		multipartConcurrency = 1
+	}
+
+	return &OSSStorage{
+		oss:                  ossclient,
+		bucket:               bucket,
+		region:               region,
+		prefix:               prefix,
+		chunkSize:            chunkSize,
+		multipartConcurrency: multipartConcurrency,
+	}, nil
+}
+
+// Get returns a reader to the specified OSS path.
+func (s *OSSStorage) Get(path string) (io.ReadCloser, error) {
+	path = s.addPrefix(path)
+	resp, err := s.bucket.GetResponse(path)
+	if resp == nil || err != nil {
+		if err.Error() == "The specified key does not exist." {
+			err = strata.ErrNotFound(path)
+		}
+		return nil, err
+	}
+	etag, found := resp.Header["Etag"]
+	if !found {
+		return nil, errors.New("No Etag header")
+	}
+	if len(etag) == 0 {
+		return nil, errors.New("Etag header is empty")
+	}
+	// Note: osstest does not require the trimming, but real OSS does
+	checksum, err := hex.DecodeString(strings.TrimSuffix(strings.TrimPrefix(etag[0], "\""), "\""))
+	if err != nil {
+		return nil, err
+	}
+	return strata.NewChecksummingReader(resp.Body, checksum), nil
+}
+
+// Put places the byte slice at the given path in OSS.
+// Put also sends a checksum to protect against network corruption.
+func (s *OSSStorage) Put(path string, data []byte) error {
+	path = s.addPrefix(path)
+	err := s.bucket.Put(path, data, "application/octet-stream", oss.Private)
+	return err
+}
+
+// PutReader consumes the given reader and stores it at the specified path in OSS.
+//
+// Data is streamed to OSS in chunkSize parts using an OSS multipart upload, so
+// PutReader no longer needs to buffer the whole object in memory. Each part is
+// hashed locally with MD5 as it is read, and that hash is checked against the
+// ETag that OSS returns for the part, protecting against network corruption in
+// the same spirit as the checksum that Get verifies via
+// strata.NewChecksummingReader. If the reader is exhausted before filling a
+// single chunk, PutReader falls back to a plain Put instead of paying for a
+// multipart round-trip.
+func (s *OSSStorage) PutReader(path string, reader io.Reader) error {
+	ossPath := s.addPrefix(path)
+
+	first, err := readChunk(reader, s.chunkSize)
+	if err != nil {
+		return err
+	}
+	if first.eof {
+		return s.Put(path, first.data)
+	}
+
+	multi, err := s.bucket.InitMulti(ossPath, "application/octet-stream", oss.Private)
+	if err != nil {
+		return err
+	}
+
+	return s.putMultipart(multi, first, reader)
+}
+
+// multiUploader is the subset of *oss.Multi that putMultipart drives. It
+// exists so that tests can exercise putMultipart's concurrency and abort
+// behavior against a fake, without talking to OSS.
+type multiUploader interface {
+	PutPart(n int, r io.ReadSeeker) (oss.Part, error)
+	Complete(parts []oss.Part) error
+	Abort() error
+}
+
+// putMultipart uploads first, followed by the rest of reader, as parts of
+// the multipart upload multi, using up to s.multipartConcurrency goroutines.
+func (s *OSSStorage) putMultipart(multi multiUploader, first chunk, reader io.Reader) error {
+	chunks := make(chan chunk)
+	errCh := make(chan error, 1)
+	done := make(chan struct{})
+	var reportOnce sync.Once
+	reportErr := func(err error) {
+		reportOnce.Do(func() {
+			errCh <- err
+			close(done)
+		})
+	}
+
+	parts := make([]oss.Part, 0, 16)
+	var partsMu sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < s.multipartConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case c, ok := <-chunks:
+					if !ok {
+						return
+					}
+					part, putErr := putPart(multi, c)
+					if putErr != nil {
+						// Stop on the first failure instead of looping back
+						// to range over chunks: once credentials expire or
+						// OSS starts rejecting every part, every subsequent
+						// PutPart would fail the same way, and a worker that
+						// keeps draining chunks without anyone reading errCh
+						// would either block forever writing to it (if errCh
+						// were unbuffered) or mask the failure (if it kept
+						// going). Returning here, plus feedChunks watching
+						// done below, unwinds the whole upload instead of
+						// hanging.
+						reportErr(putErr)
+						return
+					}
+					partsMu.Lock()
+					parts = append(parts, part)
+					partsMu.Unlock()
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	select {
+	case chunks <- first:
+	case <-done:
+	}
+	readErr := s.feedChunks(reader, chunks, done)
+	close(chunks)
+	wg.Wait()
+
+	if readErr != nil {
+		multi.Abort()
+		return readErr
+	}
+	select {
+	case putErr := <-errCh:
+		multi.Abort()
+		return putErr
+	default:
+	}
+
+	sortParts(parts)
+	if err := multi.Complete(parts); err != nil {
+		multi.Abort()
+		return err
+	}
+	return nil
+}
+
+// feedChunks reads the remainder of reader into chunkSize-sized chunks and
+// sends them on chunks, numbering them starting at part 2 since the caller
+// already read and sent the first chunk. feedChunks stops feeding as soon as
+// done is closed, so that it doesn't block forever sending to chunks after
+// every worker has already given up on a failed part.
+func (s *OSSStorage) feedChunks(reader io.Reader, chunks chan<- chunk, done <-chan struct{}) error {
+	for n := 2; ; n++ {
+		c, err := readChunk(reader, s.chunkSize)
+		if err != nil {
+			return err
+		}
+		if len(c.data) == 0 {
+			return nil
+		}
+		c.n = n
+		select {
+		case chunks <- c:
+		case <-done:
+			return nil
+		}
+		if c.eof {
+			return nil
+		}
+	}
+}
+
+// chunk is a single part read off of the PutReader source, along with its
+// local MD5 checksum.
+type chunk struct {
+	n    int
+	data []byte
+	md5  []byte
+	eof  bool
+}
+
+// readChunk reads up to chunkSize bytes from reader, returning the bytes read
+// and whether the reader is now exhausted.
+func readChunk(reader io.Reader, chunkSize int64) (chunk, error) {
+	buf := make([]byte, chunkSize)
+	n, err := io.ReadFull(reader, buf)
+	switch err {
+	case io.ErrUnexpectedEOF, io.EOF:
+		sum := md5.Sum(buf[:n])
+		return chunk{n: 1, data: buf[:n], md5: sum[:], eof: true}, nil
+	case nil:
+		sum := md5.Sum(buf[:n])
+		return chunk{n: 1, data: buf[:n], md5: sum[:]}, nil
+	default:
+		return chunk{}, err
+	}
+}
+
+// putPart uploads a single chunk of a multipart upload and verifies the
+// returned ETag against the chunk's local MD5.
+func putPart(multi multiUploader, c chunk) (oss.Part, error) {
+	part, err := multi.PutPart(c.n, bytes.NewReader(c.data))
+	if err != nil {
+		return oss.Part{}, err
+	}
+	etag, err := hex.DecodeString(strings.Trim(part.ETag, "\""))
+	if err != nil {
+		return oss.Part{}, err
+	}
+	if !bytes.Equal(etag, c.md5) {
+		return oss.Part{}, fmt.Errorf("OSS part %d ETag %x does not match local MD5 %x", c.n, etag, c.md5)
+	}
+	return part, nil
+}
+
+func sortParts(parts []oss.Part) {
+	for i := 1; i < len(parts); i++ {
+		for j := i; j > 0 && parts[j-1].N > parts[j].N; j-- {
+			parts[j-1], parts[j] = parts[j], parts[j-1]
+		}
+	}
+}
+
+// Delete removes the object at the given OSS path
+func (s *OSSStorage) Delete(path string) error {
+	path = s.addPrefix(path)
+	err := s.bucket.Del(path)
+	return err
+}
+
+// List returns a list of objects (up to maxSize) with the given prefix from OSS
+func (s *OSSStorage) List(prefix string, maxSize int) ([]string, error) {
+	prefix = s.addPrefix(prefix)
+	pathSeparator := ""
+	marker := ""
+
+	items := make([]string, 0, 1000)
+	for maxSize > 0 {
+		// Don't ask for more than 1000 keys at a time. This makes
+		// testing simpler because OSS will return at most 1000 keys even if you
+		// ask for more, but osstest will return more than 1000 keys if you ask
+		// for more. TODO(agf): Fix this behavior in osstest.
+		maxReqSize := 1000
+		if maxSize < 1000 {
+			maxReqSize = maxSize
+		}
+		contents, err := s.bucket.List(prefix, pathSeparator, marker, maxReqSize)
+		if err != nil {
+			return nil, err
+		}
+		maxSize -= maxReqSize
+
+		for _, key := range contents.Contents {
+			items = append(items, s.removePrefix(key.Key))
+		}
+		if contents.IsTruncated {
+			marker = s.addPrefix(items[len(items)-1])
+		} else {
+			break
+		}
+	}
+
+	return items, nil
+}
+
+// Lock is not implemented
+func (s *OSSStorage) Lock(path string) error {
+	return nil
+}
+
+// Unlock is not implemented
+func (s *OSSStorage) Unlock(path string) error {
+	return nil
+}
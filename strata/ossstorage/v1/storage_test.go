@@ -0,0 +1,117 @@
+//go:build ossv1
+// +build ossv1
+
+package ossstorage
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/denverdino/aliyungo/oss"
+)
+
+// fakeMulti stands in for *oss.Multi so that putMultipart's concurrency and
+// abort behavior can be exercised without talking to OSS.
+type fakeMulti struct {
+	mu        sync.Mutex
+	putErr    error
+	aborted   bool
+	completed bool
+}
+
+func (m *fakeMulti) PutPart(n int, r io.ReadSeeker) (oss.Part, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.putErr != nil {
+		return oss.Part{}, m.putErr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return oss.Part{}, err
+	}
+	sum := md5.Sum(data)
+	return oss.Part{N: n, ETag: fmt.Sprintf("%x", sum)}, nil
+}
+
+func (m *fakeMulti) Complete(parts []oss.Part) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.completed = true
+	return nil
+}
+
+func (m *fakeMulti) Abort() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.aborted = true
+	return nil
+}
+
+func firstChunk(data []byte) chunk {
+	sum := md5.Sum(data)
+	return chunk{n: 1, data: data, md5: sum[:]}
+}
+
+// TestPutMultipartAbortsOnRepeatedPartFailure guards against the deadlock
+// where a part upload fails repeatedly (e.g. credentials expiring
+// mid-upload): every worker should give up and putMultipart should return
+// the failure and abort the upload, instead of hanging forever.
+func TestPutMultipartAbortsOnRepeatedPartFailure(t *testing.T) {
+	s := &OSSStorage{chunkSize: MinChunkSize, multipartConcurrency: 1}
+	multi := &fakeMulti{putErr: errors.New("AccessDenied: token has expired")}
+
+	first := firstChunk(bytes.Repeat([]byte("a"), MinChunkSize))
+	rest := bytes.NewReader(bytes.Repeat([]byte("b"), MinChunkSize))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.putMultipart(multi, first, rest)
+	}()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("expected putMultipart to return the part upload error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("putMultipart deadlocked instead of returning the part upload error")
+	}
+
+	multi.mu.Lock()
+	defer multi.mu.Unlock()
+	if !multi.aborted {
+		t.Error("expected putMultipart to call Abort after a part failed")
+	}
+	if multi.completed {
+		t.Error("putMultipart should not call Complete after a part failed")
+	}
+}
+
+// TestPutMultipartSucceeds is the happy-path counterpart: multiple parts,
+// multiple worker goroutines, no failures.
+func TestPutMultipartSucceeds(t *testing.T) {
+	s := &OSSStorage{chunkSize: MinChunkSize, multipartConcurrency: 2}
+	multi := &fakeMulti{}
+
+	first := firstChunk(bytes.Repeat([]byte("a"), MinChunkSize))
+	rest := bytes.NewReader(bytes.Repeat([]byte("b"), 2*MinChunkSize))
+
+	if err := s.putMultipart(multi, first, rest); err != nil {
+		t.Fatalf("putMultipart: %v", err)
+	}
+
+	multi.mu.Lock()
+	defer multi.mu.Unlock()
+	if !multi.completed {
+		t.Error("expected putMultipart to call Complete")
+	}
+	if multi.aborted {
+		t.Error("putMultipart should not call Abort on success")
+	}
+}
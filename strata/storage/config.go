@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/facebookgo/rocks-strata/strata"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Config is the top-level shape of a --objstore.config-file document. Type
+// selects the registered backend, and Config is handed to that backend's
+// factory unparsed, so that new backends can be added without this package
+// knowing their shape.
+//
+//	type: oss
+//	config:
+//	  endpoint: oss-cn-hangzhou.aliyuncs.com
+//	  bucket: my-backups
+//	  prefix: mongo
+//	  acl: private
+type Config struct {
+	Type   string      `yaml:"type"`
+	Config interface{} `yaml:"config"`
+}
+
+// LoadConfig parses a --objstore.config-file document and builds the
+// strata.Storage it describes, using the backend registered under Type.
+func LoadConfig(data []byte) (strata.Storage, error) {
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Type == "" {
+		return nil, fmt.Errorf("storage: config is missing a top-level \"type\" field")
+	}
+	backendConfig, err := yaml.Marshal(cfg.Config)
+	if err != nil {
+		return nil, err
+	}
+	return New(cfg.Type, backendConfig)
+}
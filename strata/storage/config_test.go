@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/facebookgo/rocks-strata/strata"
+)
+
+func TestLoadConfigDispatchesToRegisteredBackend(t *testing.T) {
+	name := "test-config-backend"
+	var gotConfig []byte
+	Register(name, func(config []byte) (strata.Storage, error) {
+		gotConfig = config
+		return nil, nil
+	})
+
+	data := []byte("type: " + name + "\nconfig:\n  bucket: my-backups\n  prefix: mongo\n")
+	if _, err := LoadConfig(data); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if gotConfig == nil {
+		t.Fatal("expected the registered factory to be called")
+	}
+}
+
+func TestLoadConfigMissingType(t *testing.T) {
+	if _, err := LoadConfig([]byte("config:\n  bucket: my-backups\n")); err == nil {
+		t.Fatal("expected an error for a config file missing \"type\"")
+	}
+}
+
+func TestLoadConfigUnregisteredType(t *testing.T) {
+	_, err := LoadConfig([]byte("type: test-config-does-not-exist\nconfig:\n  bucket: my-backups\n"))
+	if err == nil {
+		t.Fatal("expected an error for an unregistered backend type")
+	}
+	const want = `storage: no backend registered under type "test-config-does-not-exist"`
+	if err.Error() != want {
+		t.Fatalf("LoadConfig error = %q, want %q", err, want)
+	}
+}
@@ -0,0 +1,45 @@
+// Package storage provides a config-driven registry of strata.Storage
+// backends, so that main packages can dispatch on a "type" field in a config
+// file instead of hard-coding a switch over an environment variable.
+package storage
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/facebookgo/rocks-strata/strata"
+)
+
+// Factory builds a strata.Storage from a backend-specific config, which is
+// the raw bytes under a config file's top-level "config" field.
+type Factory func(config []byte) (strata.Storage, error)
+
+var (
+	mu        sync.Mutex
+	factories = make(map[string]Factory)
+)
+
+// Register makes a storage backend available under the given type name.
+// Register is meant to be called from a backend package's init(), the same
+// way database/sql drivers register themselves. Register panics if called
+// twice for the same name.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, exists := factories[name]; exists {
+		panic(fmt.Sprintf("storage: Register called twice for backend %q", name))
+	}
+	factories[name] = factory
+}
+
+// New builds a strata.Storage using the factory registered under name,
+// passing it the raw backend-specific config bytes.
+func New(name string, config []byte) (strata.Storage, error) {
+	mu.Lock()
+	factory, ok := factories[name]
+	mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("storage: no backend registered under type %q", name)
+	}
+	return factory(config)
+}
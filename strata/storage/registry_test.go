@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/facebookgo/rocks-strata/strata"
+)
+
+func TestRegisterAndNew(t *testing.T) {
+	sentinel := errors.New("sentinel")
+	name := "test-registry-backend"
+	Register(name, func(config []byte) (strata.Storage, error) {
+		if string(config) != "payload" {
+			t.Fatalf("factory got config %q, want %q", config, "payload")
+		}
+		return nil, sentinel
+	})
+
+	if _, err := New(name, []byte("payload")); err != sentinel {
+		t.Fatalf("New: got err %v, want sentinel", err)
+	}
+}
+
+func TestNewUnregisteredBackend(t *testing.T) {
+	if _, err := New("test-registry-does-not-exist", nil); err == nil {
+		t.Fatal("expected an error for an unregistered backend")
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	name := "test-registry-duplicate"
+	factory := func(config []byte) (strata.Storage, error) { return nil, nil }
+	Register(name, factory)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected Register to panic on duplicate registration")
+		}
+	}()
+	Register(name, factory)
+}
@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/facebookgo/rocks-strata/strata"
+)
+
+// registerStub registers a placeholder factory for a backend type that is
+// planned but not implemented yet, so that a config file referencing it
+// fails with a clear message instead of "no backend registered".
+func registerStub(name string) {
+	Register(name, func(config []byte) (strata.Storage, error) {
+		return nil, fmt.Errorf("storage: backend %q is not implemented yet", name)
+	})
+}
+
+func init() {
+	// Backends that future requests are expected to add. Registering stubs
+	// here means a config file can reference them today and get a clear
+	// "not implemented" error instead of silently falling through.
+	registerStub("swift")
+	registerStub("gcs")
+	registerStub("azure")
+	registerStub("filesystem")
+}